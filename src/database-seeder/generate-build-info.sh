@@ -0,0 +1,14 @@
+#!/bin/bash
+# Record the release version and git commit for the database-seeder package, so
+# that packaging can embed them; run this before `bosh create-release`.
+# usage: generate-build-info.sh [<version>]
+
+set -o errexit -o nounset
+
+cd "$(dirname "${BASH_SOURCE[0]}")"
+
+version="${1:-$(git describe --tags --always --dirty)}"
+commit="$(git rev-parse --short HEAD)"
+
+printf 'VERSION=%q\nGIT_COMMIT=%q\n' "${version}" "${commit}" > build-info.env
+cat build-info.env
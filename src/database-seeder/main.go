@@ -3,13 +3,19 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 // SeedConfig describes the structure for database seeding configuration
@@ -19,104 +25,343 @@ type SeedConfig struct {
 	Password string
 }
 
-type dbCreator func(*sql.DB, SeedConfig) error
+// String describes the seed configuration, without the password.
+func (c SeedConfig) String() string {
+	return fmt.Sprintf("database %s (user %s)", c.Name, c.Username)
+}
+
+// MarshalJSON renders the seed configuration with the password redacted, so
+// that it is safe to include in logs or output.
+func (c SeedConfig) MarshalJSON() ([]byte, error) {
+	type redactedSeedConfig SeedConfig
+	safe := redactedSeedConfig(c)
+	if safe.Password != "" {
+		safe.Password = redacted
+	}
+	return json.Marshal(safe)
+}
 
-func mysqlCreator(db *sql.DB, seedConfig SeedConfig) (err error) {
+// Process exit codes
+const (
+	exitSuccess = 0
+	exitFailure = 1
+	// exitPartialFailure indicates that some, but not all, databases were seeded
+	exitPartialFailure = 2
+)
+
+// dbCreator seeds a single database, returning a description of the actions
+// taken (even on failure).
+type dbCreator func(context.Context, *sql.DB, SeedConfig) (action string, err error)
 
-	exec := func(stmt string, args ...interface{}) (sql.Result, error) {
+func mysqlCreator(ctx context.Context, db *sql.DB, seedConfig SeedConfig) (action string, err error) {
+	var actions []string
+	defer func() {
+		action = strings.Join(actions, ", ")
+	}()
+
+	// Use a single connection throughout, so that the SQL mode used for
+	// escaping is the one the statements run under
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		err = mysqlError(ctx, "connect", err)
+		return
+	}
+	defer conn.Close()
+
+	var sqlMode string
+	err = conn.QueryRowContext(ctx, "SELECT @@SESSION.sql_mode").Scan(&sqlMode)
+	if err != nil {
+		err = mysqlError(ctx, "query sql mode", err)
+		return
+	}
+	quoteString := func(value string) string {
+		return mysqlQuoteString(value, mysqlNoBackslashEscapes(sqlMode))
+	}
+
+	exec := func(operation, stmt string, args ...interface{}) (int64, error) {
 		finalStmt := fmt.Sprintf(stmt, args...)
 		// fmt.Printf("%s\n", finalStmt)
-		return db.Exec(finalStmt)
+		result, err := conn.ExecContext(ctx, finalStmt)
+		if err != nil {
+			return 0, mysqlError(ctx, operation, err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return 0, mysqlError(ctx, operation, err)
+		}
+		return rows, nil
 	}
 
 	// Create the database
-	_, err = exec("CREATE DATABASE IF NOT EXISTS `%s`", seedConfig.Name)
+	rows, err := exec("create database", "CREATE DATABASE IF NOT EXISTS %s",
+		mysqlQuoteIdentifier(seedConfig.Name))
 	if err != nil {
-		return err
+		return
+	}
+	if rows > 0 {
+		actions = append(actions, "created database")
 	}
 
 	// Create the user, or set the password if it already exists
-	result, err := exec("CREATE USER IF NOT EXISTS `%s` IDENTIFIED BY '%s'",
-		seedConfig.Username, seedConfig.Password)
+	rows, err = exec("create user", "CREATE USER IF NOT EXISTS %s IDENTIFIED BY %s",
+		mysqlQuoteIdentifier(seedConfig.Username), quoteString(seedConfig.Password))
 	if err != nil {
-		return err
-	}
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return err
+		return
 	}
 	if rows < 1 {
-		_, err := exec("ALTER USER `%s` IDENTIFIED BY '%s'",
-			seedConfig.Username, seedConfig.Password)
+		_, err = exec("alter user", "ALTER USER %s IDENTIFIED BY %s",
+			mysqlQuoteIdentifier(seedConfig.Username), quoteString(seedConfig.Password))
 		if err != nil {
-			return err
+			return
 		}
+		actions = append(actions, "updated password")
+	} else {
+		actions = append(actions, "created user")
 	}
 
 	// Grant privileges
-	_, err = exec("GRANT ALL ON `%s`.* TO `%s`@`%%`", seedConfig.Name, seedConfig.Username)
+	_, err = exec("grant privileges", "GRANT ALL ON %s.* TO %s@`%%`",
+		mysqlQuoteIdentifier(seedConfig.Name), mysqlQuoteIdentifier(seedConfig.Username))
 	if err != nil {
-		return err
+		return
 	}
 
-	_, err = exec("REVOKE LOCK TABLES ON `%s`.* FROM `%s`@`%%`", seedConfig.Name, seedConfig.Username)
-	if err != nil {
-		return err
+	_, err = exec("revoke privileges", "REVOKE LOCK TABLES ON %s.* FROM %s@`%%`",
+		mysqlQuoteIdentifier(seedConfig.Name), mysqlQuoteIdentifier(seedConfig.Username))
+	return
+}
+
+// mysqlQuoteIdentifier quotes a database or user name for use in a MySQL
+// statement, escaping any embedded backticks.
+func mysqlQuoteIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// mysqlNoBackslashEscapes checks whether the given SQL mode disables
+// backslash escapes in string literals.
+func mysqlNoBackslashEscapes(sqlMode string) bool {
+	for _, mode := range strings.Split(sqlMode, ",") {
+		if strings.EqualFold(strings.TrimSpace(mode), "NO_BACKSLASH_ESCAPES") {
+			return true
+		}
+	}
+	return false
+}
+
+// mysqlQuoteString quotes a value as a MySQL string literal.  If
+// noBackslashEscapes is set (the server is in NO_BACKSLASH_ESCAPES SQL mode),
+// only quotes are escaped (by doubling); otherwise, special characters are
+// escaped with backslashes, matching the driver's own parameter interpolation.
+func mysqlQuoteString(value string, noBackslashEscapes bool) string {
+	if noBackslashEscapes {
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
 	}
+	replacer := strings.NewReplacer(
+		"\x00", `\0`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\x1a", `\Z`,
+		`'`, `\'`,
+		`"`, `\"`,
+		`\`, `\\`,
+	)
+	return "'" + replacer.Replace(value) + "'"
+}
 
-	return nil
+// mysqlEscapedForms returns the escaped forms (without the surrounding quotes)
+// a value may take in a statement, under either SQL mode, where they differ
+// from the raw value; server errors quoting a statement would show these.
+func mysqlEscapedForms(value string) []string {
+	var forms []string
+	for _, noBackslashEscapes := range []bool{false, true} {
+		quoted := mysqlQuoteString(value, noBackslashEscapes)
+		escaped := quoted[1 : len(quoted)-1]
+		if escaped != value {
+			forms = append(forms, escaped)
+		}
+	}
+	return forms
+}
+
+// mysqlError wraps an error from a MySQL operation as a SeedError, classifying
+// it by the MySQL error number where available.
+func mysqlError(ctx context.Context, operation string, err error) error {
+	seedErr := &SeedError{
+		Category:  errorCategoryConnection,
+		Operation: operation,
+		Err:       err,
+	}
+	if ctx.Err() != nil {
+		seedErr.Category = errorCategoryCancelled
+	} else if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		seedErr.Code = int(mysqlErr.Number)
+		switch mysqlErr.Number {
+		case 1044, 1045, 1142, 1227:
+			// Access denied to database / user / table, or missing privilege
+			seedErr.Category = errorCategoryPermission
+		default:
+			seedErr.Category = errorCategoryDatabase
+		}
+	}
+	return seedErr
 }
 
 func main() {
-	var driver, dsn, seedConfigsJSON string
+	var driver, dsn, seedConfigsJSON, resultPath string
+	var showVersion bool
 
 	flag.StringVar(&driver, "driver", "mysql", "Database driver to use")
 	flag.StringVar(&dsn, "dsn", "", "Database connection string (DSN) to use (SEEDER_DSN)")
 	flag.StringVar(&seedConfigsJSON, "seed-configs", "", "Database seeding configuration, as a JSON string (SEEDER_CONFIGS)")
+	flag.StringVar(&resultPath, "result-file", "", "Path to write a JSON summary of the results to (SEEDER_RESULT_FILE)")
+	flag.BoolVar(&showVersion, "version", false, "Print version information and exit")
 	flag.Parse()
 
+	if showVersion {
+		fmt.Println(versionString())
+		return
+	}
+
+	fmt.Printf("Starting %s\n", versionString())
+
 	if dsn == "" {
 		dsn = os.Getenv("SEEDER_DSN")
 	}
 	if seedConfigsJSON == "" {
 		seedConfigsJSON = os.Getenv("SEEDER_CONFIGS")
 	}
+	if resultPath == "" {
+		resultPath = os.Getenv("SEEDER_RESULT_FILE")
+	}
+
+	// All further output goes through the scrubber, so that passwords never
+	// show up in logs or the result file
+	secrets := &scrubber{}
+	if dsnConfig, err := mysql.ParseDSN(dsn); driver == "mysql" && err == nil {
+		secrets.add(dsnConfig.Passwd)
+	}
+	stdout := secrets.writer(os.Stdout)
+	stderr := secrets.writer(os.Stderr)
+	// The driver logs some connection errors itself; scrub those too
+	mysql.SetLogger(log.New(stderr, "[mysql] ", log.LstdFlags))
+
+	result := &SeedResult{Databases: []DatabaseResult{}}
+	exit := func(code int) {
+		err := result.write(resultPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error writing result file %s: %v\n", resultPath, err)
+			code = exitFailure
+		}
+		os.Exit(code)
+	}
+	fail := func(operation, format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		fmt.Fprintf(stderr, "%s\n", message)
+		result.Error = secrets.scrub(message)
+		result.ErrorDetail = &SeedError{
+			Category:  errorCategoryConfig,
+			Operation: operation,
+		}
+		exit(exitFailure)
+	}
 
 	var seedConfigs []SeedConfig
 	err := json.Unmarshal([]byte(seedConfigsJSON), &seedConfigs)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not parse seed configs: %v\n", err)
-		os.Exit(1)
+		fail("parse seed configs", "Could not parse seed configs: %v", err)
+	}
+	for _, seedConfig := range seedConfigs {
+		secrets.add(seedConfig.Password)
+		secrets.add(mysqlEscapedForms(seedConfig.Password)...)
+	}
+	if configJSON, err := json.Marshal(seedConfigs); err == nil {
+		fmt.Fprintf(stdout, "Seed configuration: %s\n", configJSON)
 	}
+	validationErrors := validateSeedConfigs(seedConfigs)
 
 	db, err := sql.Open(driver, dsn)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error connecting to database: %s\n", err)
-		os.Exit(1)
+		fail("open database", "Error connecting to database: %s", err)
 	}
 
 	creator := map[string]dbCreator{
 		"mysql": mysqlCreator,
 	}[driver]
 	if creator == nil {
-		fmt.Fprintf(os.Stderr, "Error locating db creator for driver %s\n", driver)
-		os.Exit(1)
+		fail("locate db creator", "Error locating db creator for driver %s", driver)
 	}
 
-	hasError := false
+	// Cancel any in-flight statements when asked to terminate
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		// Restore the default handling, so a second signal kills the process
+		signal.Stop(signals)
+		fmt.Fprintf(stderr, "Received %s, cancelling...\n", sig)
+		cancel()
+	}()
 
-	for _, seedConfig := range seedConfigs {
-		fmt.Printf("Seeding database %s (user %s)...\n", seedConfig.Name, seedConfig.Username)
-		err = creator(db, seedConfig)
+	for i, seedConfig := range seedConfigs {
+		if err := validationErrors[i]; err != nil {
+			fmt.Fprintf(stderr, "Invalid seed config %d (%s): %v\n", i, seedConfig, err)
+			result.Databases = append(result.Databases, DatabaseResult{
+				Name:     seedConfig.Name,
+				Username: seedConfig.Username,
+				Error:    secrets.scrub(err.Error()),
+				ErrorDetail: &SeedError{
+					Category:  errorCategoryConfig,
+					Operation: "validate seed config",
+					Err:       err,
+				},
+			})
+			continue
+		}
+		if ctx.Err() != nil {
+			result.Databases = append(result.Databases, DatabaseResult{
+				Name:     seedConfig.Name,
+				Username: seedConfig.Username,
+				Error:    ctx.Err().Error(),
+				ErrorDetail: &SeedError{
+					Category:  errorCategoryCancelled,
+					Operation: "seed",
+				},
+			})
+			continue
+		}
+		fmt.Fprintf(stdout, "Seeding %s...\n", seedConfig)
+		dbResult := DatabaseResult{
+			Name:     seedConfig.Name,
+			Username: seedConfig.Username,
+			Success:  true,
+		}
+		start := time.Now()
+		dbResult.Action, err = creator(ctx, db, seedConfig)
+		dbResult.Duration = time.Since(start)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating database %s: %v\n", seedConfig.Name, err)
-			hasError = true
+			fmt.Fprintf(stderr, "Error creating database %s: %v\n", seedConfig.Name, err)
+			dbResult.Success = false
+			dbResult.Error = secrets.scrub(err.Error())
+			if seedErr, ok := err.(*SeedError); ok {
+				dbResult.ErrorDetail = seedErr
+			}
 		}
+		result.Databases = append(result.Databases, dbResult)
 	}
 
-	if hasError {
-		os.Exit(1)
+	result.printSummary(stdout)
+
+	switch failed := result.failedCount(); {
+	case failed == 0:
+	case failed < len(result.Databases):
+		exit(exitPartialFailure)
+	default:
+		exit(exitFailure)
 	}
 
-	fmt.Printf("Database seeding complete.")
+	result.Success = true
+	fmt.Fprintf(stdout, "Database seeding complete.\n")
+	exit(exitSuccess)
 }
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubDriver is a database/sql driver that records the statements executed
+// through it, without talking to a real server.
+type stubDriver struct {
+	mu         sync.Mutex
+	statements []string
+	// rowsAffected returns the result for an executed statement; if nil, all
+	// statements affect one row.
+	rowsAffected func(query string) int64
+	// sqlMode is returned when querying the session SQL mode.
+	sqlMode string
+}
+
+var stubDriverCount int
+
+// newStubDB registers a new stub driver and returns a database using it.
+func newStubDB(t *testing.T, stub *stubDriver) *sql.DB {
+	stubDriverCount++
+	name := fmt.Sprintf("seeder-test-stub-%d", stubDriverCount)
+	sql.Register(name, stub)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("could not open stub database: %v", err)
+	}
+	return db
+}
+
+func (d *stubDriver) Open(name string) (driver.Conn, error) {
+	return &stubConn{driver: d}, nil
+}
+
+type stubConn struct {
+	driver *stubDriver
+}
+
+func (c *stubConn) Prepare(query string) (driver.Stmt, error) {
+	return &stubStmt{conn: c, query: query}, nil
+}
+
+func (c *stubConn) Close() error {
+	return nil
+}
+
+func (c *stubConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type stubStmt struct {
+	conn  *stubConn
+	query string
+}
+
+func (s *stubStmt) Close() error {
+	return nil
+}
+
+func (s *stubStmt) NumInput() int {
+	return -1
+}
+
+func (s *stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.conn.driver
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, s.query)
+	if d.rowsAffected != nil {
+		return driver.RowsAffected(d.rowsAffected(s.query)), nil
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *stubStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.query != "SELECT @@SESSION.sql_mode" {
+		return nil, fmt.Errorf("unexpected query %q", s.query)
+	}
+	return &stubRows{values: []driver.Value{s.conn.driver.sqlMode}}, nil
+}
+
+// stubRows is a single-column result set.
+type stubRows struct {
+	values []driver.Value
+}
+
+func (r *stubRows) Columns() []string {
+	return []string{"value"}
+}
+
+func (r *stubRows) Close() error {
+	return nil
+}
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+func TestMysqlCreatorCancelled(t *testing.T) {
+	stub := &stubDriver{}
+	db := newStubDB(t, stub)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := mysqlCreator(ctx, db, SeedConfig{Name: "db", Username: "user", Password: "pass"})
+	if err == nil {
+		t.Fatal("expected an error seeding with a cancelled context")
+	}
+	seedErr, ok := err.(*SeedError)
+	if !ok {
+		t.Fatalf("expected a *SeedError, got %T: %v", err, err)
+	}
+	if seedErr.Category != errorCategoryCancelled {
+		t.Errorf("expected category %q, got %q", errorCategoryCancelled, seedErr.Category)
+	}
+	if seedErr.Err != context.Canceled {
+		t.Errorf("expected underlying error %v, got %v", context.Canceled, seedErr.Err)
+	}
+	if len(stub.statements) > 0 {
+		t.Errorf("expected no statements to be executed, got %q", stub.statements)
+	}
+}
+
+func TestMysqlCreatorAction(t *testing.T) {
+	testCases := []struct {
+		name       string
+		dbExists   bool
+		userExists bool
+		action     string
+		altered    bool
+	}{
+		{name: "new database and user", action: "created database, created user"},
+		{name: "existing database", dbExists: true, action: "created user"},
+		{name: "existing user", userExists: true, action: "created database, updated password", altered: true},
+		{name: "existing database and user", dbExists: true, userExists: true, action: "updated password", altered: true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			stub := &stubDriver{
+				rowsAffected: func(query string) int64 {
+					switch {
+					case strings.HasPrefix(query, "CREATE DATABASE") && testCase.dbExists:
+						return 0
+					case strings.HasPrefix(query, "CREATE USER") && testCase.userExists:
+						return 0
+					}
+					return 1
+				},
+			}
+			db := newStubDB(t, stub)
+			defer db.Close()
+
+			action, err := mysqlCreator(context.Background(), db, SeedConfig{Name: "db", Username: "user", Password: "pass"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if action != testCase.action {
+				t.Errorf("expected action %q, got %q", testCase.action, action)
+			}
+			altered := false
+			for _, statement := range stub.statements {
+				if strings.HasPrefix(statement, "ALTER USER") {
+					altered = true
+				}
+			}
+			if altered != testCase.altered {
+				t.Errorf("expected ALTER USER to be run: %v, statements: %q", testCase.altered, stub.statements)
+			}
+		})
+	}
+}
+
+func TestSeedConfigRedaction(t *testing.T) {
+	seedConfig := SeedConfig{Name: "db", Username: "user", Password: "hunter2"}
+
+	if actual := seedConfig.String(); actual != "database db (user user)" {
+		t.Errorf("unexpected string %q", actual)
+	}
+
+	encoded, err := json.Marshal([]SeedConfig{seedConfig, {Name: "empty"}})
+	if err != nil {
+		t.Fatalf("could not marshal seed config: %v", err)
+	}
+	expected := `[{"Name":"db","Username":"user","Password":"REDACTED"},` +
+		`{"Name":"empty","Username":"","Password":""}]`
+	if string(encoded) != expected {
+		t.Errorf("expected %s, got %s", expected, encoded)
+	}
+
+	// Redaction must not affect parsing the configuration
+	var decoded SeedConfig
+	err = json.Unmarshal([]byte(`{"name":"db","username":"user","password":"hunter2"}`), &decoded)
+	if err != nil {
+		t.Fatalf("could not unmarshal seed config: %v", err)
+	}
+	if decoded != seedConfig {
+		t.Errorf("expected %#v, got %#v", seedConfig, decoded)
+	}
+}
+
+func TestMysqlQuoteIdentifier(t *testing.T) {
+	testCases := map[string]string{
+		"plain":         "`plain`",
+		"back`tick":     "`back``tick`",
+		"it's":          "`it's`",
+		`back\slash`:    "`back\\slash`",
+		"nul\x00":       "`nul\x00`",
+		"100%":          "`100%`",
+		"`; DROP x; --": "```; DROP x; --`",
+	}
+	for input, expected := range testCases {
+		if actual := mysqlQuoteIdentifier(input); actual != expected {
+			t.Errorf("quoting %q: expected %s, got %s", input, expected, actual)
+		}
+	}
+}
+
+func TestMysqlQuoteString(t *testing.T) {
+	testCases := []struct {
+		input             string
+		backslashEscapes  string
+		noBackslashEscape string
+	}{
+		{"plain", `'plain'`, `'plain'`},
+		{"back`tick", "'back`tick'", "'back`tick'"},
+		{"it's", `'it\'s'`, `'it''s'`},
+		{`back\slash`, `'back\\slash'`, `'back\slash'`},
+		{"nul\x00", `'nul\0'`, "'nul\x00'"},
+		{"100%", `'100%'`, `'100%'`},
+		{`\'; DROP x; --`, `'\\\'; DROP x; --'`, `'\''; DROP x; --'`},
+	}
+	for _, testCase := range testCases {
+		if actual := mysqlQuoteString(testCase.input, false); actual != testCase.backslashEscapes {
+			t.Errorf("quoting %q: expected %s, got %s", testCase.input, testCase.backslashEscapes, actual)
+		}
+		if actual := mysqlQuoteString(testCase.input, true); actual != testCase.noBackslashEscape {
+			t.Errorf("quoting %q with NO_BACKSLASH_ESCAPES: expected %s, got %s",
+				testCase.input, testCase.noBackslashEscape, actual)
+		}
+	}
+}
+
+func TestMysqlCreatorQuoting(t *testing.T) {
+	seedConfig := SeedConfig{Name: "100%`db", Username: "o'user%", Password: `p\a'ss%`}
+	testCases := []struct {
+		sqlMode  string
+		password string
+	}{
+		{sqlMode: "STRICT_TRANS_TABLES", password: `'p\\a\'ss%'`},
+		{sqlMode: "STRICT_TRANS_TABLES,NO_BACKSLASH_ESCAPES", password: `'p\a''ss%'`},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.sqlMode, func(t *testing.T) {
+			stub := &stubDriver{sqlMode: testCase.sqlMode}
+			db := newStubDB(t, stub)
+			defer db.Close()
+
+			_, err := mysqlCreator(context.Background(), db, seedConfig)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := []string{
+				"CREATE DATABASE IF NOT EXISTS `100%``db`",
+				"CREATE USER IF NOT EXISTS `o'user%` IDENTIFIED BY " + testCase.password,
+				"GRANT ALL ON `100%``db`.* TO `o'user%`@`%`",
+				"REVOKE LOCK TABLES ON `100%``db`.* FROM `o'user%`@`%`",
+			}
+			if strings.Join(stub.statements, "\n") != strings.Join(expected, "\n") {
+				t.Errorf("expected statements:\n%s\ngot:\n%s",
+					strings.Join(expected, "\n"), strings.Join(stub.statements, "\n"))
+			}
+		})
+	}
+}
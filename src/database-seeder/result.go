@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// SeedResult is the machine-readable summary of a seeding run, written to the
+// result file (if any) so that the orchestrator can collect it even if the
+// log output is truncated.
+type SeedResult struct {
+	Success     bool             `json:"success"`
+	Error       string           `json:"error,omitempty"`
+	ErrorDetail *SeedError       `json:"error_detail,omitempty"`
+	Databases   []DatabaseResult `json:"databases"`
+}
+
+// DatabaseResult describes the outcome of seeding a single database.
+type DatabaseResult struct {
+	Name        string        `json:"name"`
+	Username    string        `json:"username"`
+	Success     bool          `json:"success"`
+	Action      string        `json:"action,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	ErrorDetail *SeedError    `json:"error_detail,omitempty"`
+	Duration    time.Duration `json:"duration_ns"`
+}
+
+// Error categories, used to classify a SeedError
+const (
+	errorCategoryConfig     = "config"
+	errorCategoryConnection = "connection"
+	errorCategoryPermission = "permission"
+	errorCategoryDatabase   = "database"
+	errorCategoryCancelled  = "cancelled"
+)
+
+// SeedError is a classified error from seeding, suitable for automation to
+// decide whether a failure is worth retrying.
+type SeedError struct {
+	// Category is one of the errorCategory* values.
+	Category string `json:"category"`
+	// Code is the database-specific error number, if any.
+	Code int `json:"code,omitempty"`
+	// Operation is the step that was being attempted.
+	Operation string `json:"operation"`
+	Err       error  `json:"-"`
+}
+
+func (e *SeedError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Operation, e.Err)
+}
+
+// write the result to the given path atomically; if the path is empty, this
+// does nothing.
+func (r *SeedResult) write(path string) error {
+	if path == "" {
+		return nil
+	}
+	contents, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(append(contents, '\n'))
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// TempFile creates the file as 0600; the result holds no secrets, and may
+	// be collected by a different user.
+	err = os.Chmod(tempFile.Name(), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), path)
+}
+
+// failedCount returns the number of databases that were not seeded successfully.
+func (r *SeedResult) failedCount() int {
+	failed := 0
+	for _, dbResult := range r.Databases {
+		if !dbResult.Success {
+			failed++
+		}
+	}
+	return failed
+}
+
+// printSummary writes a human-readable table of the per-database results,
+// followed by an aggregate status line.
+func (r *SeedResult) printSummary(w io.Writer) {
+	succeeded := 0
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "DATABASE\tUSER\tACTION\tDURATION\tRESULT\n")
+	for _, dbResult := range r.Databases {
+		status := "ok"
+		if dbResult.Success {
+			succeeded++
+		} else {
+			status = "FAILED"
+		}
+		action := dbResult.Action
+		if action == "" {
+			action = "-"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n",
+			dbResult.Name, dbResult.Username, action,
+			dbResult.Duration.Round(time.Millisecond), status)
+	}
+	table.Flush()
+	fmt.Fprintf(w, "%d of %d databases seeded successfully.\n", succeeded, len(r.Databases))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSeedResultWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seeder-result-")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "result.json")
+
+	result := &SeedResult{
+		Success:   true,
+		Databases: []DatabaseResult{{Name: "db", Username: "user", Success: true}},
+	}
+	err = result.write(path)
+	if err != nil {
+		t.Fatalf("could not write result: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("could not stat result file: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0644 {
+		t.Errorf("expected result file mode 0644, got %#o", mode)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read result file: %v", err)
+	}
+	var decoded SeedResult
+	err = json.Unmarshal(contents, &decoded)
+	if err != nil {
+		t.Fatalf("could not parse result file: %v", err)
+	}
+	if !decoded.Success || len(decoded.Databases) != 1 || decoded.Databases[0].Name != "db" {
+		t.Errorf("unexpected result contents: %s", contents)
+	}
+
+	// No temporary files should be left behind
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("could not list directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the result file, found %d entries", len(entries))
+	}
+}
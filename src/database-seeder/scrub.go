@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// scrubber redacts known secret values (such as passwords) from output, so
+// that they do not leak via unexpected error messages.
+type scrubber struct {
+	secrets  []string
+	replacer *strings.Replacer
+}
+
+// add registers additional secret values to be redacted; empty values are
+// ignored.
+func (s *scrubber) add(secrets ...string) {
+	for _, secret := range secrets {
+		if secret != "" {
+			s.secrets = append(s.secrets, secret)
+		}
+	}
+	// Prefer the longest match, in case one secret contains another
+	sort.Slice(s.secrets, func(i, j int) bool {
+		return len(s.secrets[i]) > len(s.secrets[j])
+	})
+	pairs := make([]string, 0, len(s.secrets)*2)
+	for _, secret := range s.secrets {
+		pairs = append(pairs, secret, redacted)
+	}
+	s.replacer = strings.NewReplacer(pairs...)
+}
+
+// scrub returns the text with all known secrets redacted.
+func (s *scrubber) scrub(text string) string {
+	if s.replacer == nil {
+		return text
+	}
+	return s.replacer.Replace(text)
+}
+
+// writer wraps the given writer so that everything written through it is
+// scrubbed.  Secrets are only detected within a single Write call.
+func (s *scrubber) writer(w io.Writer) io.Writer {
+	return &scrubbingWriter{scrubber: s, w: w}
+}
+
+type scrubbingWriter struct {
+	scrubber *scrubber
+	w        io.Writer
+}
+
+func (w *scrubbingWriter) Write(p []byte) (int, error) {
+	_, err := io.WriteString(w.w, w.scrubber.scrub(string(p)))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestScrubber(t *testing.T) {
+	testCases := []struct {
+		name     string
+		secrets  []string
+		input    string
+		expected string
+	}{
+		{
+			name:     "no secrets",
+			input:    "nothing to hide",
+			expected: "nothing to hide",
+		},
+		{
+			name:     "empty secrets are ignored",
+			secrets:  []string{"", ""},
+			input:    "nothing to hide",
+			expected: "nothing to hide",
+		},
+		{
+			name:     "all occurrences",
+			secrets:  []string{"hunter2"},
+			input:    "password hunter2 is hunter2",
+			expected: "password REDACTED is REDACTED",
+		},
+		{
+			name:     "longest overlapping secret wins",
+			secrets:  []string{"pass", "password123"},
+			input:    "using password123 and pass",
+			expected: "using REDACTED and REDACTED",
+		},
+		{
+			name:     "longest wins regardless of order added",
+			secrets:  []string{"password123", "pass"},
+			input:    "password123",
+			expected: "REDACTED",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			secrets := &scrubber{}
+			secrets.add(testCase.secrets...)
+			if actual := secrets.scrub(testCase.input); actual != testCase.expected {
+				t.Errorf("expected %q, got %q", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestScrubberAddIncrementally(t *testing.T) {
+	secrets := &scrubber{}
+	secrets.add("first")
+	secrets.add("second")
+	expected := "REDACTED REDACTED"
+	if actual := secrets.scrub("first second"); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestScrubbingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	secrets := &scrubber{}
+	w := secrets.writer(&buf)
+
+	// Secrets added after the writer is created still apply
+	secrets.add("hunter2")
+	input := "Error: password hunter2 rejected\n"
+	n, err := fmt.Fprint(w, input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != len(input) {
+		t.Errorf("expected %d bytes written, got %d", len(input), n)
+	}
+	expected := "Error: password REDACTED rejected\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestScrubberEscapedPasswords(t *testing.T) {
+	password := `hun\ter'2`
+	secrets := &scrubber{}
+	secrets.add(password)
+	secrets.add(mysqlEscapedForms(password)...)
+
+	inputs := []string{
+		"password " + password,
+		"near 'IDENTIFIED BY " + mysqlQuoteString(password, false) + "' at line 1",
+		"near 'IDENTIFIED BY " + mysqlQuoteString(password, true) + "' at line 1",
+	}
+	for _, input := range inputs {
+		actual := secrets.scrub(input)
+		if strings.Contains(actual, "ter") {
+			t.Errorf("password leaked scrubbing %q: got %q", input, actual)
+		}
+	}
+
+	if forms := mysqlEscapedForms("plain"); len(forms) != 0 {
+		t.Errorf("expected no escaped forms for a plain password, got %q", forms)
+	}
+}
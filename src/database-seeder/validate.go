@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDatabaseNameLength is the limit on database names, in characters; it is
+// the same across MySQL 5.6 - 8.0 and MariaDB.  User name limits vary between
+// servers (16, 32, or 80 characters), so those are left for the server.
+const maxDatabaseNameLength = 64
+
+// validateIdentifier checks that the given identifier can be used (quoted) as
+// a database or user name.  A maxLength of zero means no limit is enforced.
+func validateIdentifier(kind, value string, maxLength int) error {
+	switch {
+	case value == "":
+		return fmt.Errorf("%s must not be empty", kind)
+	case !utf8.ValidString(value):
+		return fmt.Errorf("%s %q is not valid UTF-8", kind, value)
+	case maxLength > 0 && utf8.RuneCountInString(value) > maxLength:
+		return fmt.Errorf("%s %q is %d characters long; the maximum is %d",
+			kind, value, utf8.RuneCountInString(value), maxLength)
+	case strings.HasSuffix(value, " "):
+		return fmt.Errorf("%s %q must not end with a space", kind, value)
+	case strings.ContainsRune(value, 0):
+		return fmt.Errorf("%s %q must not contain NUL characters", kind, value)
+	}
+	for _, r := range value {
+		// MySQL identifiers are limited to the Basic Multilingual Plane
+		if r > 0xFFFF {
+			return fmt.Errorf("%s %q must not contain characters above U+FFFF (found %U)",
+				kind, value, r)
+		}
+	}
+	return nil
+}
+
+// validateSeedConfigs checks the seed configurations before any requests are
+// made, so that mistakes are reported descriptively instead of as SQL errors.
+// The result has one entry per seed config, which is nil if it is valid.
+func validateSeedConfigs(seedConfigs []SeedConfig) []error {
+	type seedKey struct{ name, username string }
+	errs := make([]error, len(seedConfigs))
+	seen := make(map[seedKey]bool)
+	passwords := make(map[string]string)
+	for i, seedConfig := range seedConfigs {
+		var problems []string
+		err := validateIdentifier("database name", seedConfig.Name, maxDatabaseNameLength)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		err = validateIdentifier("user name", seedConfig.Username, 0)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		if len(problems) > 0 {
+			// Invalid entries are never seeded, so they cannot conflict with
+			// later ones
+			errs[i] = errors.New(strings.Join(problems, "; "))
+			continue
+		}
+		// A database may have multiple users, but each pair should only be
+		// listed once
+		key := seedKey{seedConfig.Name, seedConfig.Username}
+		if seen[key] {
+			problems = append(problems, fmt.Sprintf("database %s with user %s is listed more than once",
+				seedConfig.Name, seedConfig.Username))
+		}
+		// Users may own multiple databases, but they can only have one password
+		if password, ok := passwords[seedConfig.Username]; ok && password != seedConfig.Password {
+			problems = append(problems, fmt.Sprintf("user %s is listed with conflicting passwords",
+				seedConfig.Username))
+		}
+		if len(problems) > 0 {
+			errs[i] = errors.New(strings.Join(problems, "; "))
+			continue
+		}
+		seen[key] = true
+		passwords[seedConfig.Username] = seedConfig.Password
+	}
+	return errs
+}
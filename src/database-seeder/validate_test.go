@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSeedConfigs(t *testing.T) {
+	seedConfigs := []SeedConfig{
+		{Name: "db1", Username: "user1", Password: "pw1"},
+		// Same database, different user
+		{Name: "db1", Username: "user2", Password: "pw2"},
+		// Same user, different database, same password
+		{Name: "db2", Username: "user1", Password: "pw1"},
+		// Database names at the limit, and user names of any length (the
+		// limit for those depends on the server)
+		{Name: strings.Repeat("d", 64), Username: strings.Repeat("u", 100), Password: "pw"},
+		// Characters within the Basic Multilingual Plane
+		{Name: "dätenbank", Username: "ユーザー", Password: "pw"},
+		// Invalid entries
+		{Name: "db1", Username: "user1", Password: "pw1"},
+		{Name: "", Username: "user3", Password: "pw3"},
+		{Name: "db3 ", Username: "user3", Password: "pw3"},
+		{Name: "db4", Username: "user\x00", Password: "pw4"},
+		{Name: "db5", Username: "user2", Password: "other"},
+		{Name: strings.Repeat("d", 65), Username: "user6", Password: "pw6"},
+		{Name: "db\U0001F600", Username: "user7", Password: "pw7"},
+		{Name: "db8", Username: "user\xff", Password: "pw8"},
+		// An invalid entry is never seeded, so it cannot conflict with a later
+		// valid entry for the same user
+		{Name: "", Username: "user9", Password: "a"},
+		{Name: "db9", Username: "user9", Password: "b"},
+		{Name: "db10 ", Username: "user10", Password: "pw10"},
+		{Name: "db10 ", Username: "user10", Password: "pw10"},
+	}
+	expected := []string{
+		"",
+		"",
+		"",
+		"",
+		"",
+		"database db1 with user user1 is listed more than once",
+		"database name must not be empty",
+		`database name "db3 " must not end with a space`,
+		`user name "user\x00" must not contain NUL characters`,
+		"user user2 is listed with conflicting passwords",
+		`database name "` + strings.Repeat("d", 65) + `" is 65 characters long; the maximum is 64`,
+		`database name "db😀" must not contain characters above U+FFFF (found U+1F600)`,
+		`user name "user\xff" is not valid UTF-8`,
+		"database name must not be empty",
+		"",
+		`database name "db10 " must not end with a space`,
+		`database name "db10 " must not end with a space`,
+	}
+
+	errs := validateSeedConfigs(seedConfigs)
+	if len(errs) != len(seedConfigs) {
+		t.Fatalf("expected %d results, got %d", len(seedConfigs), len(errs))
+	}
+	for i, err := range errs {
+		actual := ""
+		if err != nil {
+			actual = err.Error()
+		}
+		if actual != expected[i] {
+			t.Errorf("seed config %d: expected error %q, got %q", i, expected[i], actual)
+		}
+	}
+}
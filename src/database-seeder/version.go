@@ -0,0 +1,16 @@
+package main
+
+import "fmt"
+
+// Build information; these are overridden at link time via
+// -ldflags "-X main.version=... -X main.gitCommit=... -X main.buildDate=...".
+var (
+	version   = "dev"
+	gitCommit = "unknown"
+	buildDate = "unknown"
+)
+
+// versionString returns a human-readable description of this build.
+func versionString() string {
+	return fmt.Sprintf("database-seeder %s (commit %s, built %s)", version, gitCommit, buildDate)
+}